@@ -0,0 +1,253 @@
+// Package httpcache is a small on-disk cache for the raw FMI XML responses.
+//
+// Entries are stored as individual files named after the SHA-256 of their
+// cache key (the encoded query string), written with the same
+// write-to-temp-then-rename pattern used elsewhere in this project so a
+// crash never leaves a partially written entry behind. Callers decide
+// freshness themselves by passing a TTL into Get, since that TTL depends on
+// which year is being requested (this year's data changes, past years
+// don't).
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// entry tracks the on-disk state of a single cached response.
+type entry struct {
+	path       string
+	size       int64
+	storedAt   time.Time
+	lastAccess time.Time
+}
+
+// Stats summarizes the current state of a Cache, suitable for exposing over
+// an HTTP endpoint.
+type Stats struct {
+	Entries int   `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Stale   int64 `json:"stale"`
+}
+
+// Cache is a size-capped, LRU-evicted, on-disk cache of raw FMI XML
+// responses.
+type Cache struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	entries map[string]*entry
+
+	hits   int64
+	misses int64
+	stale  int64
+}
+
+// New opens (and creates if necessary) a cache rooted at dir, capped at
+// maxBytes of on-disk entries. Existing entries from a previous run are
+// picked back up.
+func New(dir string, maxBytes int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	c := &Cache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		entries:  make(map[string]*entry),
+	}
+
+	if err := c.loadIndex(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Cache) loadIndex() error {
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(f.Name(), "._new_") {
+			// Left behind by a Put that crashed between creating its temp
+			// file and renaming it into place; not a real entry.
+			continue
+		}
+
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+
+		c.entries[f.Name()] = &entry{
+			path:       filepath.Join(c.dir, f.Name()),
+			size:       info.Size(),
+			storedAt:   info.ModTime(),
+			lastAccess: info.ModTime(),
+		}
+	}
+
+	return nil
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get looks up key and reports whether the entry is stale relative to ttl.
+// found is false if there is no entry at all, in which case stale is
+// meaningless.
+func (c *Cache) Get(key string, ttl time.Duration) (data []byte, stale bool, found bool, err error) {
+	name := hashKey(key)
+
+	c.mu.Lock()
+	e, ok := c.entries[name]
+	c.mu.Unlock()
+
+	if !ok {
+		atomic.AddInt64(&c.misses, 1)
+		return nil, false, false, nil
+	}
+
+	data, err = os.ReadFile(e.path)
+	if err != nil {
+		return nil, false, false, err
+	}
+
+	stale = time.Since(e.storedAt) > ttl
+
+	c.mu.Lock()
+	e.lastAccess = time.Now()
+	c.mu.Unlock()
+
+	atomic.AddInt64(&c.hits, 1)
+	if stale {
+		atomic.AddInt64(&c.stale, 1)
+	}
+
+	return data, stale, true, nil
+}
+
+// Put stores data under key, atomically replacing any previous entry, and
+// evicts the least-recently-used entries until the cache fits within
+// maxBytes.
+func (c *Cache) Put(key string, data []byte) error {
+	name := hashKey(key)
+	path := filepath.Join(c.dir, name)
+	tmpPath := filepath.Join(c.dir, "._new_"+name)
+
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	c.entries[name] = &entry{path: path, size: int64(len(data)), storedAt: now, lastAccess: now}
+	c.mu.Unlock()
+
+	return c.evict()
+}
+
+// evict removes least-recently-used entries until the cache is within
+// maxBytes. Callers must not hold c.mu.
+func (c *Cache) evict() error {
+	if c.maxBytes <= 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var total int64
+	for _, e := range c.entries {
+		total += e.size
+	}
+
+	for total > c.maxBytes {
+		var oldestName string
+		var oldest *entry
+		for name, e := range c.entries {
+			if oldest == nil || e.lastAccess.Before(oldest.lastAccess) {
+				oldestName, oldest = name, e
+			}
+		}
+		if oldest == nil {
+			break
+		}
+
+		if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		total -= oldest.size
+		delete(c.entries, oldestName)
+	}
+
+	return nil
+}
+
+// Purge removes every entry from the cache, on disk and in memory.
+func (c *Cache) Purge() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name, e := range c.entries {
+		if err := os.Remove(e.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		delete(c.entries, name)
+	}
+
+	return nil
+}
+
+// Stats reports the current size and hit/miss counters of the cache.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var bytes int64
+	for _, e := range c.entries {
+		bytes += e.size
+	}
+
+	return Stats{
+		Entries: len(c.entries),
+		Bytes:   bytes,
+		Hits:    atomic.LoadInt64(&c.hits),
+		Misses:  atomic.LoadInt64(&c.misses),
+		Stale:   atomic.LoadInt64(&c.stale),
+	}
+}
@@ -0,0 +1,26 @@
+// Package logctx threads a request-scoped *slog.Logger through a
+// context.Context, so handlers and the functions they call can log with
+// consistent attributes (request_id, remote_addr, fmisid, year, ...)
+// without passing a logger argument everywhere.
+package logctx
+
+import (
+	"context"
+	"log/slog"
+)
+
+type ctxKey struct{}
+
+// With returns a copy of ctx carrying logger, for From to later retrieve.
+func With(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// From returns the logger attached to ctx by With, or slog.Default if none
+// was attached.
+func From(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
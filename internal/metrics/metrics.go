@@ -0,0 +1,107 @@
+// Package metrics holds the Prometheus collectors for this service:
+// application-level metrics about fetching and parsing FMI data, and
+// per-station observation gauges for whatever has been fetched so far.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/saltsa/weather_simplified/internal/render"
+)
+
+var (
+	FetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "weather_fetch_duration_seconds",
+		Help: "Time spent fetching observations from the FMI API, labeled by station.",
+	}, []string{"fmisid"})
+
+	UpstreamStatus = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "weather_upstream_http_status_total",
+		Help: "Count of HTTP status codes returned by the FMI API.",
+	}, []string{"status"})
+
+	XMLParseDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "weather_xml_parse_duration_seconds",
+		Help: "Time spent unmarshalling FMI WFS XML responses.",
+	})
+
+	CacheResult = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "weather_cache_result_total",
+		Help: "Count of cache lookups, labeled by result (hit, stale or miss).",
+	}, []string{"result"})
+
+	InFlightRequests = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "weather_inflight_requests",
+		Help: "Number of /weather requests currently being served.",
+	})
+
+	TempMin = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "weather_temp_min",
+		Help: "Minimum observed temperature for a station and date.",
+	}, []string{"fmisid", "date"})
+
+	TempMax = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "weather_temp_max",
+		Help: "Maximum observed temperature for a station and date.",
+	}, []string{"fmisid", "date"})
+
+	TempAvg = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "weather_temp_avg",
+		Help: "Average observed temperature for a station and date.",
+	}, []string{"fmisid", "date"})
+
+	HelleDays = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "weather_helle_days",
+		Help: "Count of hellepäivä (max temp over 25C) days observed so far in a year, labeled by station and year.",
+	}, []string{"fmisid", "year"})
+)
+
+// Handler returns the HTTP handler to mount at /metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RecordObservations updates the per-station temperature gauges and the
+// hellepäivä gauge for a fetched year of data. Every value is Set rather
+// than accumulated, so calling this repeatedly for the same fmisid/year
+// (a cache hit, a poller re-fetching the same year) just re-reports the
+// same totals instead of inflating them. Only the daily-temp query's
+// schema (min/avg/max columns) is recorded today; other queries are a
+// no-op here until they get gauges of their own.
+func RecordObservations(fmisid, year string, wd render.WeatherData) {
+	minIdx := columnIndex(wd.Columns, "min")
+	avgIdx := columnIndex(wd.Columns, "avg")
+	maxIdx := columnIndex(wd.Columns, "max")
+	if minIdx < 0 || avgIdx < 0 || maxIdx < 0 {
+		return
+	}
+	helleIdx := columnIndex(wd.Columns, "helle")
+
+	var helleCount float64
+
+	for _, r := range wd.Rows {
+		TempMin.WithLabelValues(fmisid, r.Timestamp).Set(r.Values[minIdx])
+		TempMax.WithLabelValues(fmisid, r.Timestamp).Set(r.Values[maxIdx])
+		TempAvg.WithLabelValues(fmisid, r.Timestamp).Set(r.Values[avgIdx])
+		if helleIdx >= 0 && r.Values[helleIdx] != 0 {
+			helleCount++
+		}
+	}
+
+	HelleDays.WithLabelValues(fmisid, year).Set(helleCount)
+}
+
+// columnIndex returns the index of name within columns, or -1 if it isn't
+// one of them.
+func columnIndex(columns []string, name string) int {
+	for i, c := range columns {
+		if c == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,258 @@
+// Package observations declares the FMI stored queries this service knows
+// how to fetch and decode: which WFS stored query and parameters to request
+// upstream, at what time granularity, and how to turn the raw elements of
+// the response into Rows with a query-specific column schema.
+package observations
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	dayLayout  = "2006-01-02"
+	hourLayout = "2006-01-02T15:04"
+)
+
+// Element is one decoded <BsWfsElement> from an FMI WFS response: a single
+// parameter's value at a point in time.
+type Element struct {
+	Time           time.Time
+	ParameterName  string
+	ParameterValue float64
+}
+
+// Row is one decoded observation for a single time step (a day, an hour, a
+// lightning stroke...). Values line up with the owning Query's Columns, so
+// callers that don't know the specific query can still render or forward
+// the data generically.
+type Row interface {
+	Timestamp() string
+	Values() []float64
+}
+
+// Query describes one FMI stored query this service can fetch and render:
+// which stored query and parameters to ask for, the time step observations
+// are grouped at, and how to decode the resulting Elements into Rows.
+type Query struct {
+	Name          string // value accepted by the /weather ?query= parameter
+	StoredQueryID string
+	Parameters    []string
+	TimeStep      time.Duration
+	Aggregation   string // "daily", "hourly" or "stroke", informational
+	Columns       []string
+	Decode        func(elements []Element) []Row
+}
+
+// Registry holds every query this service knows how to serve, keyed by
+// Name.
+var Registry = map[string]*Query{}
+
+func register(q *Query) {
+	Registry[q.Name] = q
+}
+
+// Lookup returns the named query, defaulting to "daily-temp" when name is
+// empty. ok is false if name doesn't match a registered query.
+func Lookup(name string) (q *Query, ok bool) {
+	if name == "" {
+		name = "daily-temp"
+	}
+	q, ok = Registry[name]
+	return q, ok
+}
+
+// ParseTimestamp parses a Row's Timestamp string back into a time.Time,
+// trying each granularity this package's queries can produce.
+func ParseTimestamp(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(hourLayout, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(dayLayout, s)
+}
+
+type row struct {
+	ts     string
+	values []float64
+}
+
+func (r row) Timestamp() string { return r.ts }
+func (r row) Values() []float64 { return r.values }
+
+func last(existing, value float64) float64 { return value }
+
+// bucketKey returns the row key t belongs to at the given granularity: a
+// day, an hour (truncated down to it), or the full timestamp.
+func bucketKey(t time.Time, step time.Duration) string {
+	lt := t.Local()
+	switch {
+	case step >= 24*time.Hour:
+		return lt.Format(dayLayout)
+	case step >= time.Hour:
+		return lt.Truncate(time.Hour).Format(hourLayout)
+	default:
+		return lt.Format(time.RFC3339)
+	}
+}
+
+// decodeRows groups elements into Rows keyed by the time bucket implied by
+// step, placing each element's value into the column paramColumn maps its
+// ParameterName to. Repeated values landing in the same bucket and column
+// are combined with combine; the first value for a bucket+column is kept
+// as-is.
+func decodeRows(elements []Element, step time.Duration, paramColumn map[string]int, numColumns int, combine func(existing, value float64) float64) []Row {
+	byKey := make(map[string][]float64)
+	var order []string
+
+	for _, e := range elements {
+		col, ok := paramColumn[e.ParameterName]
+		if !ok {
+			continue
+		}
+
+		k := bucketKey(e.Time, step)
+		values, seen := byKey[k]
+		if !seen {
+			values = make([]float64, numColumns)
+			for i := range values {
+				values[i] = math.NaN()
+			}
+			order = append(order, k)
+		}
+
+		if math.IsNaN(values[col]) {
+			values[col] = e.ParameterValue
+		} else {
+			values[col] = combine(values[col], e.ParameterValue)
+		}
+		byKey[k] = values
+	}
+
+	sort.Strings(order)
+
+	rows := make([]Row, 0, len(order))
+	for _, k := range order {
+		rows = append(rows, row{ts: k, values: byKey[k]})
+	}
+	return rows
+}
+
+// decodeDailyTemp is decodeRows for the daily-temp query, plus the derived
+// "helle" (hellepäivä, max temp over 25C) column that isn't a raw FMI
+// parameter.
+func decodeDailyTemp(elements []Element) []Row {
+	rows := decodeRows(elements, 24*time.Hour, map[string]int{
+		"tmin": 0,
+		"tday": 1,
+		"tmax": 2,
+	}, 3, last)
+
+	out := make([]Row, 0, len(rows))
+	for _, r := range rows {
+		v := r.Values()
+		helle := 0.0
+		if v[2] > 25.0 {
+			helle = 1.0
+		}
+		out = append(out, row{ts: r.Timestamp(), values: append(v, helle)})
+	}
+	return out
+}
+
+func init() {
+	register(&Query{
+		Name:          "daily-temp",
+		StoredQueryID: "fmi::observations::weather::daily::simple",
+		Parameters:    []string{"tmin", "tday", "tmax"},
+		TimeStep:      24 * time.Hour,
+		Aggregation:   "daily",
+		Columns:       []string{"min", "avg", "max", "helle"},
+		Decode:        decodeDailyTemp,
+	})
+
+	register(&Query{
+		Name:          "hourly",
+		StoredQueryID: "fmi::observations::weather::simple",
+		Parameters:    []string{"t2m", "ws_10min", "wd_10min", "rh", "p_sea", "r_1h"},
+		TimeStep:      time.Hour,
+		Aggregation:   "hourly",
+		Columns:       []string{"temp", "wind_speed", "wind_dir", "humidity", "pressure", "precip"},
+		Decode: func(elements []Element) []Row {
+			return decodeRows(elements, time.Hour, map[string]int{
+				"t2m":      0,
+				"ws_10min": 1,
+				"wd_10min": 2,
+				"rh":       3,
+				"p_sea":    4,
+				"r_1h":     5,
+			}, 6, last)
+		},
+	})
+
+	register(&Query{
+		Name:          "precip",
+		StoredQueryID: "fmi::observations::weather::daily::simple",
+		Parameters:    []string{"rrday"},
+		TimeStep:      24 * time.Hour,
+		Aggregation:   "daily",
+		Columns:       []string{"precip_sum"},
+		Decode: func(elements []Element) []Row {
+			return decodeRows(elements, 24*time.Hour, map[string]int{"rrday": 0}, 1, last)
+		},
+	})
+
+	register(&Query{
+		Name:          "lightning",
+		StoredQueryID: "fmi::observations::lightning::stroke::simple",
+		Parameters:    []string{"peak_current", "multiplicity"},
+		TimeStep:      time.Second,
+		Aggregation:   "stroke",
+		Columns:       []string{"peak_current", "multiplicity"},
+		Decode:        decodeLightning,
+	})
+}
+
+// decodeLightning turns stroke elements into one Row per stroke.
+//
+// Unlike decodeRows's bucket-and-combine model (built for continuous
+// sensor aggregates, where a day or an hour is a natural grouping key),
+// lightning strokes are discrete events: two strokes can land in the same
+// second, and bucketing by truncated time would silently collapse them
+// into one, discarding whichever value arrived second. Instead, elements
+// are grouped by their position in the response: a new stroke starts
+// whenever a column would otherwise be overwritten, which is however FMI
+// orders repeated parameters for distinct strokes.
+func decodeLightning(elements []Element) []Row {
+	const peakCurrent, multiplicity = 0, 1
+	columns := map[string]int{"peak_current": peakCurrent, "multiplicity": multiplicity}
+
+	var rows []Row
+	var cur []float64
+	var curTime time.Time
+
+	flush := func() {
+		if cur != nil {
+			rows = append(rows, row{ts: curTime.Local().Format(time.RFC3339Nano), values: cur})
+		}
+	}
+
+	for _, e := range elements {
+		col, ok := columns[e.ParameterName]
+		if !ok {
+			continue
+		}
+		if cur == nil || !math.IsNaN(cur[col]) {
+			flush()
+			cur = []float64{math.NaN(), math.NaN()}
+			curTime = e.Time
+		}
+		cur[col] = e.ParameterValue
+	}
+	flush()
+
+	return rows
+}
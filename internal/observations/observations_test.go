@@ -0,0 +1,108 @@
+package observations
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, s string) time.Time {
+	t.Helper()
+	ts, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatalf("parsing %q: %v", s, err)
+	}
+	return ts
+}
+
+func TestDecodeDailyTemp(t *testing.T) {
+	elements := []Element{
+		{Time: mustParse(t, "2023-07-18T00:00:00Z"), ParameterName: "tmin", ParameterValue: 14.2},
+		{Time: mustParse(t, "2023-07-18T00:00:00Z"), ParameterName: "tday", ParameterValue: 19.8},
+		{Time: mustParse(t, "2023-07-18T00:00:00Z"), ParameterName: "tmax", ParameterValue: 22.1},
+		{Time: mustParse(t, "2023-07-19T00:00:00Z"), ParameterName: "tmin", ParameterValue: 17.0},
+		{Time: mustParse(t, "2023-07-19T00:00:00Z"), ParameterName: "tmax", ParameterValue: 26.5},
+	}
+
+	rows := decodeDailyTemp(elements)
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+
+	day1, day2 := rows[0], rows[1]
+	if day1.Timestamp() != "2023-07-18" || day2.Timestamp() != "2023-07-19" {
+		t.Fatalf("unexpected row order: %s, %s", day1.Timestamp(), day2.Timestamp())
+	}
+
+	if day1.Values()[3] != 0 {
+		t.Errorf("day1 helle = %v, want 0 (max 22.1 is not a hellepäivä)", day1.Values()[3])
+	}
+	if day2.Values()[3] != 1 {
+		t.Errorf("day2 helle = %v, want 1 (max 26.5 is a hellepäivä)", day2.Values()[3])
+	}
+
+	// day2 has no "tday" element at all, a normal gap in real FMI data;
+	// decodeRows leaves the corresponding column as NaN rather than 0, so
+	// callers can tell "missing" from "observed zero".
+	if !math.IsNaN(day2.Values()[1]) {
+		t.Errorf("day2 avg = %v, want NaN for a missing tday", day2.Values()[1])
+	}
+}
+
+func TestDecodeRowsUnknownParameterIgnored(t *testing.T) {
+	elements := []Element{
+		{Time: mustParse(t, "2023-07-18T00:00:00Z"), ParameterName: "tmin", ParameterValue: 14.2},
+		{Time: mustParse(t, "2023-07-18T00:00:00Z"), ParameterName: "some_unknown_param", ParameterValue: 999},
+	}
+
+	rows := decodeRows(elements, 24*time.Hour, map[string]int{"tmin": 0}, 1, last)
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows, want 1", len(rows))
+	}
+	if rows[0].Values()[0] != 14.2 {
+		t.Errorf("tmin = %v, want 14.2", rows[0].Values()[0])
+	}
+}
+
+func TestBucketKeyGranularity(t *testing.T) {
+	ts := mustParse(t, "2023-07-18T14:37:00Z")
+
+	if got := bucketKey(ts, 24*time.Hour); got != ts.Local().Format(dayLayout) {
+		t.Errorf("daily bucketKey = %q, want a day", got)
+	}
+	if got := bucketKey(ts, time.Hour); got != ts.Local().Truncate(time.Hour).Format(hourLayout) {
+		t.Errorf("hourly bucketKey = %q, want the truncated hour", got)
+	}
+	if got := bucketKey(ts, time.Second); got != ts.Local().Format(time.RFC3339) {
+		t.Errorf("sub-minute bucketKey = %q, want the full timestamp", got)
+	}
+}
+
+func TestDecodeLightningKeepsStrokesInTheSameSecond(t *testing.T) {
+	ts := mustParse(t, "2023-07-18T20:14:05Z")
+	elements := []Element{
+		{Time: ts, ParameterName: "peak_current", ParameterValue: -12.5},
+		{Time: ts, ParameterName: "multiplicity", ParameterValue: 1},
+		{Time: ts, ParameterName: "peak_current", ParameterValue: 34.2},
+		{Time: ts, ParameterName: "multiplicity", ParameterValue: 2},
+	}
+
+	rows := decodeLightning(elements)
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2 (one per stroke, same second)", len(rows))
+	}
+	if rows[0].Values()[0] != -12.5 || rows[1].Values()[0] != 34.2 {
+		t.Errorf("peak_current values = %v, %v, want -12.5, 34.2", rows[0].Values()[0], rows[1].Values()[0])
+	}
+}
+
+func TestLookupDefaultsToDailyTemp(t *testing.T) {
+	q, ok := Lookup("")
+	if !ok || q.Name != "daily-temp" {
+		t.Fatalf("Lookup(\"\") = %v, %v, want the daily-temp query", q, ok)
+	}
+
+	if _, ok := Lookup("not-a-real-query"); ok {
+		t.Errorf("Lookup of an unregistered name should report ok=false")
+	}
+}
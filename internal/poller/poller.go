@@ -0,0 +1,171 @@
+// Package poller configures and drives a periodic push of daily weather
+// observations into InfluxDB, as an alternative (or complement) to serving
+// them on demand over HTTP.
+package poller
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"gopkg.in/yaml.v3"
+
+	"github.com/saltsa/weather_simplified/internal/logctx"
+	"github.com/saltsa/weather_simplified/internal/observations"
+	"github.com/saltsa/weather_simplified/internal/render"
+)
+
+const measurement = "weather_daily"
+
+// Station is one FMI station this poller keeps pushing to InfluxDB.
+type Station struct {
+	FMISID string   `yaml:"fmisid"`
+	Name   string   `yaml:"name"`
+	Years  []string `yaml:"years"`
+}
+
+// InfluxConfig holds the connection details for the target InfluxDB bucket.
+type InfluxConfig struct {
+	URL    string `yaml:"url"`
+	Token  string `yaml:"token"`
+	Org    string `yaml:"org"`
+	Bucket string `yaml:"bucket"`
+}
+
+// Config is the poller's config file: which stations/years to poll, on what
+// schedule, and where to push the resulting points.
+type Config struct {
+	Influx          InfluxConfig `yaml:"influx"`
+	IntervalSeconds int          `yaml:"interval_seconds"`
+	Stations        []Station    `yaml:"stations"`
+}
+
+// Interval returns how often to poll, defaulting to 15 minutes if unset.
+func (c *Config) Interval() time.Duration {
+	if c.IntervalSeconds <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(c.IntervalSeconds) * time.Second
+}
+
+// LoadConfig reads and parses a poller config file.
+func LoadConfig(path string) (*Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading poller config: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing poller config: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+// Writer pushes daily observations to InfluxDB and tracks what's already
+// been written so repeated polls don't duplicate points.
+type Writer struct {
+	client   influxdb2.Client
+	writeAPI api.WriteAPIBlocking
+	queryAPI api.QueryAPI
+	org      string
+	bucket   string
+}
+
+// NewWriter builds a Writer from an InfluxConfig.
+func NewWriter(cfg InfluxConfig) *Writer {
+	client := influxdb2.NewClient(cfg.URL, cfg.Token)
+	return &Writer{
+		client:   client,
+		writeAPI: client.WriteAPIBlocking(cfg.Org, cfg.Bucket),
+		queryAPI: client.QueryAPI(cfg.Org),
+		org:      cfg.Org,
+		bucket:   cfg.Bucket,
+	}
+}
+
+// Close releases the underlying InfluxDB client.
+func (w *Writer) Close() {
+	w.client.Close()
+}
+
+// LastWritten returns the timestamp of the most recent point already stored
+// for fmisid, so WritePoints can skip re-sending it. ok is false if nothing
+// has been written yet.
+func (w *Writer) LastWritten(ctx context.Context, fmisid string) (t time.Time, ok bool, err error) {
+	flux := fmt.Sprintf(`
+from(bucket: %q)
+  |> range(start: -10y)
+  |> filter(fn: (r) => r._measurement == %q and r.fmisid == %q)
+  |> last()
+`, w.bucket, measurement, fmisid)
+
+	result, err := w.queryAPI.Query(ctx, flux)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	defer result.Close()
+
+	for result.Next() {
+		t = result.Record().Time()
+		ok = true
+	}
+
+	return t, ok, result.Err()
+}
+
+// WritePoints pushes rows newer than since (or all of them, if since is the
+// zero Time) for the given station. Each of wd's declared Columns becomes
+// an Influx field, so this works for any registered query, not just
+// daily-temp. Columns with no value for a row (a gap in the upstream data,
+// which decodeRows represents as NaN) are left out of that point rather
+// than sent as a NaN field, since the line protocol rejects those outright;
+// a row whose write fails is logged and skipped rather than aborting the
+// rest of the batch.
+func (w *Writer) WritePoints(ctx context.Context, st Station, year string, wd render.WeatherData, since time.Time) error {
+	logger := logctx.From(ctx)
+
+	for _, r := range wd.Rows {
+		ts, err := observations.ParseTimestamp(r.Timestamp)
+		if err != nil {
+			continue
+		}
+		if !since.IsZero() && !ts.After(since) {
+			continue
+		}
+
+		fields := make(map[string]interface{}, len(wd.Columns))
+		for i, col := range wd.Columns {
+			if math.IsNaN(r.Values[i]) {
+				continue
+			}
+			fields[col] = r.Values[i]
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		p := write.NewPoint(measurement,
+			map[string]string{
+				"fmisid": st.FMISID,
+				"name":   st.Name,
+				"year":   year,
+			},
+			fields,
+			ts,
+		)
+
+		if err := w.writeAPI.WritePoint(ctx, p); err != nil {
+			logger.Error("failed to write point, skipping row", "timestamp", r.Timestamp, "error", err)
+			continue
+		}
+	}
+
+	return nil
+}
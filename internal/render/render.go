@@ -0,0 +1,182 @@
+// Package render turns a WeatherData result into one of the response
+// formats the /weather/{id} endpoint can serve: the original fixed-width
+// text, JSON, or CSV.
+package render
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Format identifies a response encoding.
+type Format string
+
+const (
+	Text Format = "text"
+	JSON Format = "json"
+	CSV  Format = "csv"
+)
+
+// Row is one observation row in a response: a day, an hour, or a lightning
+// stroke, depending on the query that produced it. Values line up with the
+// owning WeatherData's Columns, in order.
+type Row struct {
+	Timestamp string    `json:"timestamp"`
+	Values    []float64 `json:"values"`
+}
+
+// MarshalJSON renders Values with NaN entries as JSON null. NaN is this
+// package's normal encoding for a missing observation (a gap in the
+// upstream data for that column), but encoding/json can't represent it
+// directly and errors on any row that has one.
+func (r Row) MarshalJSON() ([]byte, error) {
+	values := make([]interface{}, len(r.Values))
+	for i, v := range r.Values {
+		if math.IsNaN(v) {
+			continue
+		}
+		values[i] = v
+	}
+
+	return json.Marshal(struct {
+		Timestamp string        `json:"timestamp"`
+		Values    []interface{} `json:"values"`
+	}{r.Timestamp, values})
+}
+
+// WeatherData is the format-independent result rendered by this package.
+// Rows must already be sorted by Timestamp. Columns names the query's
+// schema: what each entry in a Row's Values means, and in what order.
+type WeatherData struct {
+	FMISID     string   `json:"fmisid"`
+	Year       string   `json:"year"`
+	Query      string   `json:"query"`
+	Columns    []string `json:"columns"`
+	Rows       []Row    `json:"rows"`
+	HelleCount int64    `json:"helleCount,omitempty"`
+}
+
+// columnIndex returns the index of name within columns, or -1 if it isn't
+// one of them.
+func columnIndex(columns []string, name string) int {
+	for i, c := range columns {
+		if c == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Negotiate picks a Format from an explicit "?format=" query value, falling
+// back to the request's Accept header, and finally to Text if neither names
+// a format this package knows about.
+func Negotiate(queryFormat, accept string) Format {
+	switch strings.ToLower(queryFormat) {
+	case "json":
+		return JSON
+	case "csv":
+		return CSV
+	case "text":
+		return Text
+	}
+
+	switch {
+	case strings.Contains(accept, "application/json"):
+		return JSON
+	case strings.Contains(accept, "text/csv"):
+		return CSV
+	default:
+		return Text
+	}
+}
+
+// ContentType returns the Content-Type header value for a Format.
+func ContentType(f Format) string {
+	switch f {
+	case JSON:
+		return "application/json"
+	case CSV:
+		return "text/csv"
+	default:
+		return "text/plain"
+	}
+}
+
+// Render writes data to w in the given format.
+func Render(w io.Writer, f Format, data WeatherData) error {
+	switch f {
+	case JSON:
+		return renderJSON(w, data)
+	case CSV:
+		return renderCSV(w, data)
+	default:
+		return renderText(w, data)
+	}
+}
+
+func renderJSON(w io.Writer, data WeatherData) error {
+	return json.NewEncoder(w).Encode(data)
+}
+
+func renderCSV(w io.Writer, data WeatherData) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(append([]string{"timestamp"}, data.Columns...)); err != nil {
+		return err
+	}
+
+	for _, r := range data.Rows {
+		row := make([]string, 0, len(data.Columns)+1)
+		row = append(row, r.Timestamp)
+		for i, col := range data.Columns {
+			if col == "helle" {
+				row = append(row, strconv.FormatBool(r.Values[i] != 0))
+				continue
+			}
+			row = append(row, strconv.FormatFloat(r.Values[i], 'f', 2, 64))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// renderText prints one line per row, with each column rendered as
+// "name=value", based on the query's declared schema. The "helle" column,
+// when present, is rendered as a hellepäivä marker instead of a number, and
+// adds the running total at the end, matching the original daily-temp
+// output.
+func renderText(w io.Writer, data WeatherData) error {
+	helleIdx := columnIndex(data.Columns, "helle")
+
+	for _, r := range data.Rows {
+		fmt.Fprintf(w, "%-16s", r.Timestamp)
+		for i, col := range data.Columns {
+			if i == helleIdx {
+				continue
+			}
+			fmt.Fprintf(w, " %s=%-7.2f", col, r.Values[i])
+		}
+		if helleIdx >= 0 {
+			helle := ""
+			if r.Values[helleIdx] != 0 {
+				helle = "hellepäivä"
+			}
+			fmt.Fprintf(w, " %16s", helle)
+		}
+		fmt.Fprintln(w)
+	}
+
+	if helleIdx >= 0 {
+		fmt.Fprintf(w, "\nTotal number of hellepäivät: %d\n", data.HelleCount)
+	}
+	return nil
+}
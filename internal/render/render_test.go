@@ -0,0 +1,107 @@
+package render
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	cases := []struct {
+		name        string
+		queryFormat string
+		accept      string
+		want        Format
+	}{
+		{"query param wins", "csv", "application/json", CSV},
+		{"accept json", "", "application/json, text/plain", JSON},
+		{"accept csv", "", "text/csv", CSV},
+		{"unrecognized query param falls back to accept", "xml", "application/json", JSON},
+		{"no match defaults to text", "", "*/*", Text},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Negotiate(tc.queryFormat, tc.accept); got != tc.want {
+				t.Errorf("Negotiate(%q, %q) = %s, want %s", tc.queryFormat, tc.accept, got, tc.want)
+			}
+		})
+	}
+}
+
+func sampleData() WeatherData {
+	return WeatherData{
+		FMISID:  "100971",
+		Year:    "2023",
+		Query:   "daily-temp",
+		Columns: []string{"min", "avg", "max", "helle"},
+		Rows: []Row{
+			{Timestamp: "2023-07-18", Values: []float64{14.2, 19.8, 22.1, 0}},
+			{Timestamp: "2023-07-19", Values: []float64{17.0, 23.4, 26.5, 1}},
+		},
+		HelleCount: 1,
+	}
+}
+
+func TestRenderText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Render(&buf, Text, sampleData()); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "hellepäivä") {
+		t.Errorf("text output missing hellepäivä marker:\n%s", out)
+	}
+	if !strings.Contains(out, "Total number of hellepäivät: 1") {
+		t.Errorf("text output missing helle total:\n%s", out)
+	}
+}
+
+func TestRenderCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Render(&buf, CSV, sampleData()); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	out := buf.String()
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if lines[0] != "timestamp,min,avg,max,helle" {
+		t.Errorf("header = %q", lines[0])
+	}
+	if !strings.Contains(lines[2], "true") {
+		t.Errorf("helle column not rendered as bool: %q", lines[2])
+	}
+}
+
+func TestRenderJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Render(&buf, JSON, sampleData()); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{`"fmisid":"100971"`, `"helleCount":1`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("json output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+// TestRenderJSONWithGap covers a row with a missing column (NaN), the
+// normal encoding for a day/hour that's missing one of its parameters
+// (see observations.decodeRows). encoding/json errors outright on a raw
+// NaN float, so this must not reach it as one.
+func TestRenderJSONWithGap(t *testing.T) {
+	data := sampleData()
+	data.Rows = append(data.Rows, Row{
+		Timestamp: "2023-07-20",
+		Values:    []float64{15.5, math.NaN(), 24.0, 0},
+	})
+
+	var buf bytes.Buffer
+	if err := Render(&buf, JSON, data); err != nil {
+		t.Fatalf("Render with a NaN column: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"values":[15.5,null,24,0]`) {
+		t.Errorf("NaN column not rendered as null:\n%s", buf.String())
+	}
+}
@@ -1,14 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
-	"io"
 	"io/ioutil"
+	"log/slog"
 	"math"
 	"os"
 	"runtime/pprof"
-	"sort"
+	"strings"
+	"sync/atomic"
 
 	"flag"
 	"net/http"
@@ -17,10 +23,17 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
-	log "github.com/sirupsen/logrus"
+	"github.com/lmittmann/tint"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 
+	"github.com/saltsa/weather_simplified/internal/httpcache"
+	"github.com/saltsa/weather_simplified/internal/logctx"
+	"github.com/saltsa/weather_simplified/internal/metrics"
+	"github.com/saltsa/weather_simplified/internal/observations"
+	"github.com/saltsa/weather_simplified/internal/poller"
+	"github.com/saltsa/weather_simplified/internal/render"
+
 	_ "net/http/pprof"
 )
 
@@ -43,259 +56,487 @@ type bwe struct {
 	ParameterValue string
 }
 
-type d struct {
-	Max float64
-	Min float64
-	Avg float64
-}
-
-func (d *d) Helle() bool {
-	if d.Max > 25.0 {
-		return true
-	}
-	return false
-}
-
-type weatherData struct {
-	FMISID string
-	Year   string
-	Dates  map[string]d
-}
-
-func check(err error) {
+// check panics on a fatal startup/IO error, logging it first via ctx's
+// logger.
+func check(ctx context.Context, err error) {
 	if err != nil {
-		log.Errorf("Got fatal error: %v", err)
+		logctx.From(ctx).Error("got fatal error", "error", err)
 		time.Sleep(2 * time.Second)
 		panic(err)
 	}
 }
 
-func fetchDataHTTP(q url.Values) ([]byte, error) {
+// fetchDataHTTP fetches q from the FMI opendata API. It uses ctx for the
+// request, so a client disconnecting (or the caller otherwise cancelling
+// ctx) aborts the upstream call instead of running to the client timeout.
+func fetchDataHTTP(ctx context.Context, q url.Values) ([]byte, error) {
+	logger := logctx.From(ctx)
 
 	u, err := url.Parse(opendataURL)
-	check(err)
+	check(ctx, err)
 
 	u.RawQuery = q.Encode()
-	url := u.String()
+	fullURL := u.String()
 
 	client := http.Client{
 		Timeout: 3 * time.Second,
 	}
 
-	log.Infof("Fetching data for FMI station %s with timeout %s", q.Get("fmisid"), client.Timeout)
-	resp, err := client.Get(url)
+	fmisid := q.Get("fmisid")
+	start := time.Now()
+	defer func() {
+		metrics.FetchDuration.WithLabelValues(fmisid).Observe(time.Since(start).Seconds())
+	}()
+
+	logger.Info("fetching data from FMI", "timeout", client.Timeout)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
 	if err != nil {
-		log.Errorf("http error: %s", err)
 		return nil, err
 	}
 
+	resp, err := client.Do(req)
+	if err != nil {
+		logger.Error("http error", "error", err)
+		return nil, err
+	}
+
+	metrics.UpstreamStatus.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
+
 	defer resp.Body.Close()
 	data, err := ioutil.ReadAll(resp.Body)
 
 	return data, err
 }
 
-func fetchData(year, fmisid string, c chan *[]byte) {
+func fetchData(ctx context.Context, cache *httpcache.Cache, year, fmisid string, query *observations.Query, c chan *[]byte) {
+	logger := logctx.From(ctx)
 
 	var data []byte
 	var err error
 	u := url.URL{}
 	q := u.Query()
 	q.Set("request", "GetFeature")
-	q.Set("storedquery_id", "fmi::observations::weather::daily::simple")
+	q.Set("storedquery_id", query.StoredQueryID)
 	//q.Set("starttime", time.Now().AddDate(0, -2, 0).Format("2006-01-02"))
 	q.Set("starttime", year+"-01-01")
 	q.Set("endtime", year+"-12-31")
 	q.Set("fmisid", fmisid) // https://ilmatieteenlaitos.fi/havaintoasemat
+	if len(query.Parameters) > 0 {
+		q.Set("parameters", strings.Join(query.Parameters, ","))
+	}
 
-	data, err = fetchDataHTTP(q)
+	data, err = cachedFetch(ctx, cache, q, year)
 	if err != nil {
-		log.Errorf("failed to fetch http data: %s", err)
+		logger.Error("failed to fetch http data", "error", err)
 		return
 	}
 
-	log.Info("File fetched and saved ok")
+	logger.Info("file fetched and saved ok")
 
 	c <- &data
 }
 
-func writeXMLToFile(data *[]byte) {
+// cacheTTLForYear returns how long a cached response for year may be served
+// before it's considered stale. FMI daily observations for past years are
+// immutable, so those are cached effectively forever; the current year is
+// still being appended to upstream, so it gets a short TTL.
+func cacheTTLForYear(year string) time.Duration {
+	if year == strconv.Itoa(time.Now().Year()) {
+		return 15 * time.Minute
+	}
+	return 100 * 365 * 24 * time.Hour
+}
+
+// cachedFetch serves q from cache when possible. A fresh hit is returned
+// immediately, a stale hit is returned immediately too but triggers an async
+// refresh (stale-while-revalidate) so a slow upstream never blocks the
+// caller, and a miss fetches synchronously.
+func cachedFetch(ctx context.Context, cache *httpcache.Cache, q url.Values, year string) ([]byte, error) {
+	logger := logctx.From(ctx)
+	key := q.Encode()
+	ttl := cacheTTLForYear(year)
+
+	data, stale, found, err := cache.Get(key, ttl)
+	if err != nil {
+		logger.Warn("cache read failed, falling back to upstream", "error", err)
+		found = false
+	}
+
+	if found && !stale {
+		metrics.CacheResult.WithLabelValues("hit").Inc()
+		logger.Debug("cache hit", "fmisid", q.Get("fmisid"), "year", year)
+		return data, nil
+	}
+
+	if found && stale {
+		metrics.CacheResult.WithLabelValues("stale").Inc()
+		logger.Debug("serving stale cache entry, refreshing in background", "fmisid", q.Get("fmisid"), "year", year)
+		// The refresh outlives this request, so detach from its
+		// cancellation but keep the request's logging attributes.
+		go refreshCache(context.WithoutCancel(ctx), cache, q, key)
+		return data, nil
+	}
+
+	metrics.CacheResult.WithLabelValues("miss").Inc()
+
+	fresh, err := fetchDataHTTP(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cache.Put(key, fresh); err != nil {
+		logger.Warn("failed to write cache entry", "error", err)
+	}
+
+	return fresh, nil
+}
+
+func refreshCache(ctx context.Context, cache *httpcache.Cache, q url.Values, key string) {
+	logger := logctx.From(ctx)
+
+	data, err := fetchDataHTTP(ctx, q)
+	if err != nil {
+		logger.Error("background cache refresh failed", "error", err)
+		return
+	}
+
+	if err := cache.Put(key, data); err != nil {
+		logger.Error("background cache refresh write failed", "error", err)
+	}
+}
+
+// runPoller drives the configured stations/years on cfg's schedule, pushing
+// daily observations into InfluxDB. ready is flipped to true once the first
+// pass completes, so /readyz reflects it.
+func runPoller(ctx context.Context, cache *httpcache.Cache, cfg *poller.Config, writer *poller.Writer, ready *atomic.Bool) {
+	pollOnce(ctx, cache, cfg, writer)
+	ready.Store(true)
+
+	ticker := time.NewTicker(cfg.Interval())
+	defer ticker.Stop()
+
+	for range ticker.C {
+		pollOnce(ctx, cache, cfg, writer)
+	}
+}
+
+// pollQuery is the observation type the poller pushes to InfluxDB: daily
+// min/avg/max, the same as the original HTTP default.
+var pollQuery, _ = observations.Lookup("daily-temp")
+
+func pollOnce(ctx context.Context, cache *httpcache.Cache, cfg *poller.Config, writer *poller.Writer) {
+	for _, st := range cfg.Stations {
+		for _, year := range st.Years {
+			pollStation(ctx, cache, writer, st, year)
+		}
+	}
+}
+
+func pollStation(ctx context.Context, cache *httpcache.Cache, writer *poller.Writer, st poller.Station, year string) {
+	logger := logctx.From(ctx).With("fmisid", st.FMISID, "year", year)
+	ctx = logctx.With(ctx, logger)
+
+	dataChan := make(chan *[]byte, 1)
+	fetchData(ctx, cache, year, st.FMISID, pollQuery, dataChan)
+
+	select {
+	case xml := <-dataChan:
+		elements := readData(ctx, xml)
+		wd := toWeatherData(st.FMISID, year, pollQuery, elements)
+		metrics.RecordObservations(st.FMISID, year, wd)
+
+		since, ok, err := writer.LastWritten(ctx, st.FMISID)
+		if err != nil {
+			logger.Error("failed to query last written point", "error", err)
+		}
+		if !ok {
+			since = time.Time{}
+		}
+
+		if err := writer.WritePoints(ctx, st, year, wd, since); err != nil {
+			logger.Error("failed to write points", "error", err)
+		}
+	case <-time.After(5 * time.Second):
+		logger.Error("poll timeout")
+	}
+}
+
+func writeXMLToFile(ctx context.Context, data *[]byte) {
 	dataFile := "failed.xml"
 	tmpPath := "._new_" + dataFile
 	f, err := os.OpenFile(tmpPath, os.O_EXCL|os.O_RDWR|os.O_CREATE, 0600) // fails if file exists
-	check(err)
+	check(ctx, err)
 	_, err = f.Write(*data)
-	check(err)
+	check(ctx, err)
 	err = f.Sync()
-	check(err)
+	check(ctx, err)
 	err = f.Close()
-	check(err)
+	check(ctx, err)
 	err = os.Rename(tmpPath, dataFile)
-	check(err)
-	log.Errorf("failed xml wrote to %s", dataFile)
+	check(ctx, err)
+	logctx.From(ctx).Error("failed xml wrote to file", "path", dataFile)
 }
 
-func readData(data *[]byte) map[string]d {
-	log.Debugf("Data received, parsing it")
+// readData unmarshals an FMI WFS response into the query-independent
+// Elements that observations.Query.Decode consumes.
+func readData(ctx context.Context, data *[]byte) []observations.Element {
+	logger := logctx.From(ctx)
+	logger.Debug("data received, parsing it")
 	fcs := &fc{}
 
 	umlStart := time.Now()
 	err := xml.Unmarshal(*data, &fcs)
-	log.Debugf("xml unmarshal took %v", time.Since(umlStart))
+	umlDuration := time.Since(umlStart)
+	metrics.XMLParseDuration.Observe(umlDuration.Seconds())
+	logger.Debug("xml unmarshal done", "duration", umlDuration)
 	if err != nil {
-		log.Printf("XML error: %s", err)
-		writeXMLToFile(data)
+		logger.Error("XML error", "error", err)
+		writeXMLToFile(ctx, data)
 		panic("xml fail")
 	}
 
-	dates := make(map[string]d)
+	elements := make([]observations.Element, 0, len(fcs.Members))
 
 	for _, b := range fcs.Members {
 
-		t, err := strconv.ParseFloat(b.ParameterValue, 64)
+		v, err := strconv.ParseFloat(b.ParameterValue, 64)
 		if err != nil {
-			log.Errorf("failed to parse value: %s", b.ParameterValue)
+			logger.Error("failed to parse value", "value", b.ParameterValue)
 			continue
 		}
 
-		if math.IsNaN(t) {
+		if math.IsNaN(v) {
 			// skip nans
 			continue
 		}
 
-		ti, err := time.Parse(time.RFC3339, b.Time)
+		t, err := time.Parse(time.RFC3339, b.Time)
 		if err != nil {
-			log.Errorf("failed to date: %s", b.Time)
+			logger.Error("failed to parse date", "time", b.Time)
 			continue
 		}
-		localDate := ti.Local().Format("2006-01-02")
-		tmp, ok := dates[localDate]
-		if !ok {
-			// new entry, use NaN as values instead of 0 as they're temps
-			tmp.Min = math.NaN()
-			tmp.Max = math.NaN()
-			tmp.Avg = math.NaN()
-		}
 
-		switch b.ParameterName {
-		case "tmax":
-			tmp.Max = t
-		case "tday":
-			tmp.Avg = t
-		case "tmin":
-			tmp.Min = t
-		}
-
-		//log.Debugf("%s ty is %s, and for it's %+v", localDate, ty, tmp)
-
-		dates[localDate] = tmp
+		elements = append(elements, observations.Element{
+			Time:           t,
+			ParameterName:  b.ParameterName,
+			ParameterValue: v,
+		})
 	}
 
-	return dates
+	return elements
 }
 
-func printDates(dates *map[string]d, target io.Writer) {
-	names := make([]string, 0, len(*dates))
-	for k := range *dates {
-		names = append(names, k)
+// toWeatherData runs query's Decode over elements and converts the result
+// into the format-independent shape the render package expects.
+func toWeatherData(fmisid, year string, query *observations.Query, elements []observations.Element) render.WeatherData {
+	rows := query.Decode(elements)
+	helleIdx := -1
+	for i, c := range query.Columns {
+		if c == "helle" {
+			helleIdx = i
+		}
 	}
 
-	sort.Strings(names)
+	wd := render.WeatherData{
+		FMISID:  fmisid,
+		Year:    year,
+		Query:   query.Name,
+		Columns: query.Columns,
+		Rows:    make([]render.Row, 0, len(rows)),
+	}
 
-	var helleCount int64
-	for _, k := range names {
-		v := (*dates)[k]
-		helle := ""
-		if v.Helle() {
-			helle = "hellepäivä"
-			helleCount++
+	for _, r := range rows {
+		values := r.Values()
+		if helleIdx >= 0 && values[helleIdx] != 0 {
+			wd.HelleCount++
 		}
-		fmt.Fprintf(target, "%-16s min=%-7.2f avg=%-7.2f max=%-7.2f %16s\n", k, v.Min, v.Avg, v.Max, helle)
+		wd.Rows = append(wd.Rows, render.Row{
+			Timestamp: r.Timestamp(),
+			Values:    values,
+		})
 	}
-	fmt.Fprintf(target, "\nTotal number of hellepäivät: %d\n", helleCount)
-	//log.Printf("read xml: %+v", fcs)
+
+	return wd
 }
 
-func init() {
-	log.SetFormatter(&log.TextFormatter{
-		FullTimestamp:   true,
-		TimestampFormat: "15:04:05.000",
-	})
-	log.SetLevel(log.DebugLevel)
+// newLogger builds the process-wide logger from -log-format and
+// -log-level: "text" uses a colorized, human-readable handler for local
+// dev, "json" emits one JSON object per line for shipping to Loki/ELK. An
+// unparseable level falls back to info.
+func newLogger(format, level string) *slog.Logger {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
+	}
 
-	// stdout is buffered hopefully
-	log.SetOutput(os.Stdout)
+	if strings.EqualFold(format, "json") {
+		return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: lvl}))
+	}
+
+	return slog.New(tint.NewHandler(os.Stdout, &tint.Options{
+		Level:      lvl,
+		TimeFormat: "15:04:05.000",
+	}))
+}
 
+// newRequestID returns a short random identifier for a request's logger,
+// falling back to a timestamp if the system RNG is unavailable.
+func newRequestID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b[:])
 }
 
-func RequestLogger(targetMux http.Handler) http.Handler {
-	log.Printf("Request logger called")
-	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+// RequestLogger wraps targetMux with access logging, attaching a
+// request-scoped logger (request_id, remote_addr) to each request's
+// context so every handler and the functions it calls log with the same
+// identifying attributes.
+func RequestLogger(base *slog.Logger, targetMux http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
-		targetMux.ServeHTTP(w, r)
+		metrics.InFlightRequests.Inc()
+		defer metrics.InFlightRequests.Dec()
 
-		// log request by who(IP address)
-		requesterIP := r.RemoteAddr
+		logger := base.With("request_id", newRequestID(), "remote_addr", r.RemoteAddr)
+		r = r.WithContext(logctx.With(r.Context(), logger))
 
-		reqDuration := time.Since(start)
+		targetMux.ServeHTTP(w, r)
 
+		reqDuration := time.Since(start)
 		vars := mux.Vars(r)
-		log.Printf("%s %s\t%v\t%s", r.Method, requesterIP, reqDuration, vars["id"])
+		logger.Info("request served", "method", r.Method, "duration", reqDuration, "id", vars["id"])
 	})
-
-	return handler
 }
 
 func main() {
 	var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to file")
 
 	defaultFmiSid := flag.String("sid", "100971", "FMI station ID, defaults to Kaisaniemi, see https://ilmatieteenlaitos.fi/havaintoasemat")
+	cacheDir := flag.String("cache-dir", "cache", "directory to store cached FMI responses in")
+	cacheMaxMB := flag.Int64("cache-max-mb", 256, "maximum size of the on-disk cache in megabytes before LRU eviction kicks in")
+	pollConfig := flag.String("poll-config", "", "path to a poller YAML config; when set, periodically pushes observations to InfluxDB instead of waiting for /weather requests")
+	logFormat := flag.String("log-format", "text", "log output format: text (colorized, for dev) or json (for shipping to Loki/ELK)")
+	logLevel := flag.String("log-level", "debug", "minimum log level: debug, info, warn or error")
 	flag.Parse()
+
+	logger := newLogger(*logFormat, *logLevel)
+	slog.SetDefault(logger)
+	ctx := logctx.With(context.Background(), logger)
+
 	if *cpuprofile != "" {
 		f, err := os.Create(*cpuprofile)
 		if err != nil {
-			log.Fatal(err)
+			logger.Error("failed to create cpu profile", "error", err)
+			os.Exit(1)
 		}
 		pprof.StartCPUProfile(f)
 		defer pprof.StopCPUProfile()
 	}
 
-	log.Printf("cmdline fmisid: %s", *defaultFmiSid)
+	logger.Info("starting", "fmisid", *defaultFmiSid)
+
+	cache, err := httpcache.New(*cacheDir, *cacheMaxMB*1024*1024)
+	check(ctx, err)
+
+	var ready atomic.Bool
+	ready.Store(true)
+
+	if *pollConfig != "" {
+		ready.Store(false)
+
+		cfg, err := poller.LoadConfig(*pollConfig)
+		check(ctx, err)
+
+		writer := poller.NewWriter(cfg.Influx)
+		defer writer.Close()
+
+		pollCtx := logctx.With(context.Background(), logger.With("component", "poller"))
+		go runPoller(pollCtx, cache, cfg, writer, &ready)
+	}
 
 	ReturnWeatherData := func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logctx.From(ctx)
+
 		dataChan := make(chan *[]byte)
 		vars := mux.Vars(r)
 
-		log.Printf("Mux vars: %+v", vars)
+		logger.Debug("mux vars", "vars", vars)
 		fmisid := *defaultFmiSid
 		if vars["id"] != "" {
 			fmisid = vars["id"]
 		}
 
 		year := r.FormValue("year")
-		log.Debugf("get for year: %s", year)
 		if len(year) != 4 {
 			year = "2019"
 		}
 
-		log.Printf("get with fmisid %s", fmisid)
-		go fetchData(year, fmisid, dataChan)
+		logger = logger.With("fmisid", fmisid, "year", year)
+		ctx = logctx.With(ctx, logger)
 
-		select {
-		case xml := <-dataChan:
-			dates := readData(xml)
-
-			d := &weatherData{}
-			d.Dates = dates
-			d.Year = year
+		queryName := r.FormValue("query")
+		query, ok := observations.Lookup(queryName)
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown query %q", queryName), http.StatusBadRequest)
+			return
+		}
 
-			d.FMISID = fmisid
+		// fetchData runs on its own context: it's canceled if the client
+		// actually goes away, but not just because our own 5s wait gives
+		// up and this handler returns — that would otherwise abort an
+		// upstream fetch that was about to succeed and warm the cache for
+		// the next request. A watcher cancels fetchCtx on a real
+		// disconnect, or once fetchData has returned either way.
+		fetchCtx, cancelFetch := context.WithCancel(context.WithoutCancel(ctx))
+		fetchDone := make(chan struct{})
+		go func() {
+			defer close(fetchDone)
+			fetchData(fetchCtx, cache, year, fmisid, query, dataChan)
+		}()
+		go func() {
+			select {
+			case <-ctx.Done():
+			case <-fetchDone:
+			}
+			cancelFetch()
+		}()
+
+		logger.Info("fetching weather data", "query", query.Name)
 
-			fmt.Fprintf(w, "Data at %v year %s:\n\n", time.Now().Format("15:04:05"), year)
-			printDates(&dates, w)
+		select {
+		case <-ctx.Done():
+			logger.Warn("client went away before data was ready")
+			return
+		case xml := <-dataChan:
+			elements := readData(ctx, xml)
+			wd := toWeatherData(fmisid, year, query, elements)
+			metrics.RecordObservations(fmisid, year, wd)
+
+			format := render.Negotiate(r.FormValue("format"), r.Header.Get("Accept"))
+
+			// Rendered into a buffer first, not straight to w: that way a
+			// render error still gets a real 5xx response instead of a
+			// half-written 200 with the content-type header already sent.
+			var buf bytes.Buffer
+			if format == render.Text {
+				fmt.Fprintf(&buf, "Data at %v year %s:\n\n", time.Now().Format("15:04:05"), year)
+			}
+
+			if err := render.Render(&buf, format, wd); err != nil {
+				logger.Error("failed to render response", "error", err)
+				http.Error(w, "failed to render response", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("content-type", render.ContentType(format))
+			w.Write(buf.Bytes())
 		case <-time.After(5 * time.Second):
-			log.Errorf("channel read timeout")
+			logger.Error("channel read timeout")
 			http.Error(w, "data read timeout", http.StatusInternalServerError)
 		}
 	}
@@ -304,13 +545,49 @@ func main() {
 		w.Header().Set("content-type", "text/plain")
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintf(w, "query server with: /weather/<fmi station id>\n")
-		fmt.Fprintf(w, "add optional ?year=NNNN for specific year")
+		fmt.Fprintf(w, "add optional ?year=NNNN for specific year\n")
+		fmt.Fprintf(w, "add optional ?query=daily-temp|hourly|precip|lightning to choose the observation type\n")
+		fmt.Fprintf(w, "add optional ?format=text|json|csv, or set the Accept header, to choose the response format")
+	}
+
+	CacheStats := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		json.NewEncoder(w).Encode(cache.Stats())
+	}
+
+	CachePurge := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed\n", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := cache.Purge(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "cache purged\n")
+	}
+
+	Healthz := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "ok\n")
+	}
+
+	Readyz := func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() {
+			http.Error(w, "not ready\n", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, "ready\n")
 	}
 
 	mux := mux.NewRouter()
 	mux.HandleFunc("/", Usage)
 	mux.HandleFunc("/weather/{id:[0-9]*}", ReturnWeatherData)
-	http.Handle("/", mux)
+	mux.HandleFunc("/cache/stats", CacheStats)
+	mux.HandleFunc("/cache/purge", CachePurge)
+	mux.HandleFunc("/healthz", Healthz)
+	mux.HandleFunc("/readyz", Readyz)
+	mux.Handle("/metrics", metrics.Handler())
+	http.Handle("/", RequestLogger(logger, mux))
 
 	// Allow http2 insecure
 	h2s := &http2.Server{}
@@ -323,6 +600,6 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 	}
 
-	log.Fatal(s.ListenAndServe())
-
+	logger.Error("server exited", "error", s.ListenAndServe())
+	os.Exit(1)
 }